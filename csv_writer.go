@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+)
+
+// csvStreamWriter writes Records to a CSV file one at a time as they arrive,
+// so a run never has to hold every record in memory before writing it out.
+type csvStreamWriter struct {
+	file       *os.File
+	writer     *csv.Writer
+	skipHeader bool
+}
+
+// newCSVStreamWriter opens filename for a fresh (truncating) write. Used for
+// the per-scraper child CSVs, which don't participate in --format/--append.
+func newCSVStreamWriter(filename string) (*csvStreamWriter, error) {
+	return newCSVOutputWriter(filename, false)
+}
+
+// newCSVOutputWriter opens filename as an OutputWriter backend. When append
+// is true and the file already exists, the existing contents (and header)
+// are preserved and new rows are written after them.
+func newCSVOutputWriter(filename string, appendMode bool) (*csvStreamWriter, error) {
+	skipHeader := false
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		if info, err := os.Stat(filename); err == nil && info.Size() > 0 {
+			skipHeader = true
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file %s: %w", filename, err)
+	}
+	return &csvStreamWriter{file: file, writer: csv.NewWriter(file), skipHeader: skipHeader}, nil
+}
+
+func (w *csvStreamWriter) WriteHeader(fields []string) error {
+	if w.skipHeader {
+		return nil
+	}
+	if err := w.writer.Write(fields); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvStreamWriter) WriteRecord(fields []string, record Record) error {
+	row := make([]string, len(fields))
+	for i, field := range fields {
+		row[i] = record[field]
+	}
+	if err := w.writer.Write(row); err != nil {
+		return fmt.Errorf("failed to write CSV record: %w", err)
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvStreamWriter) Close() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,15 @@
+package main
+
+// processDocument selects the template that matches content and extracts
+// its parent record plus any scraper rows. It's shared by the batch
+// pipeline and the serve subcommand so both paths run the exact same
+// extraction logic.
+func processDocument(content string, templates []*Template, globalScrapers []*ScraperRule) (*Template, Record, map[string][]Record, error) {
+	tmpl, err := selectTemplate(templates, content)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	record := extractRecord(content, tmpl)
+	children := runScrapers(content, scraperRules(tmpl, globalScrapers))
+	return tmpl, record, children, nil
+}
@@ -0,0 +1,13 @@
+//go:build !grpc
+
+package main
+
+import "log"
+
+// startGRPC is the default (non -tags grpc) build's stand-in: the gRPC
+// front end needs a pdfcsvpb package generated by `make proto`, which isn't
+// checked in, so without the build tag we skip it and only serve HTTP.
+func startGRPC(svc *Service, addr string) (stop func(), err error) {
+	log.Printf("gRPC server disabled (built without -tags grpc); not listening on %s", addr)
+	return func() {}, nil
+}
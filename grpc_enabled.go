@@ -0,0 +1,36 @@
+//go:build grpc
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	pb "github.com/joaopaulomendes95/pdf-csv/pdfcsvpb"
+	"google.golang.org/grpc"
+)
+
+// startGRPC starts the PdfCsv gRPC service on addr and returns a function
+// that gracefully stops it. Built only with -tags grpc, against a pdfcsvpb
+// package generated by `make proto` (see proto/pdfcsv.proto); it is not
+// checked in, so the default build excludes this file and grpc_disabled.go
+// is used instead.
+func startGRPC(svc *Service, addr string) (stop func(), err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", addr, err)
+	}
+
+	grpcSrv := grpc.NewServer()
+	pb.RegisterPdfCsvServer(grpcSrv, newGRPCServer(svc))
+
+	go func() {
+		log.Printf("gRPC server listening on %s", addr)
+		if err := grpcSrv.Serve(listener); err != nil {
+			log.Fatalf("gRPC server failed: %v", err)
+		}
+	}()
+
+	return grpcSrv.GracefulStop, nil
+}
@@ -0,0 +1,63 @@
+//go:build grpc
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	pb "github.com/joaopaulomendes95/pdf-csv/pdfcsvpb"
+)
+
+// grpcServer adapts Service onto the generated PdfCsvServer interface (see
+// proto/pdfcsv.proto, built via `make proto`).
+type grpcServer struct {
+	pb.UnimplementedPdfCsvServer
+	svc *Service
+}
+
+func newGRPCServer(svc *Service) *grpcServer {
+	return &grpcServer{svc: svc}
+}
+
+// ParseInvoice reassembles a PDF streamed in chunks and returns the single
+// invoice extracted from it.
+func (s *grpcServer) ParseInvoice(stream pb.PdfCsv_ParseInvoiceServer) error {
+	var data []byte
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("receiving PDF chunk: %w", err)
+		}
+		data = append(data, chunk.GetData()...)
+	}
+
+	record, _, err := s.svc.ParseBytes(data)
+	if err != nil {
+		return fmt.Errorf("parsing PDF: %w", err)
+	}
+	return stream.SendAndClose(recordToProto(record))
+}
+
+// ParseBatch parses every PDF in the request and streams back one Invoice
+// per input, in order, so a recurring-billing pipeline can process many
+// invoices over a single RPC.
+func (s *grpcServer) ParseBatch(req *pb.ParseBatchRequest, stream pb.PdfCsv_ParseBatchServer) error {
+	for _, pdfBytes := range req.GetPdfs() {
+		record, _, err := s.svc.ParseBytes(pdfBytes)
+		if err != nil {
+			return fmt.Errorf("parsing PDF: %w", err)
+		}
+		if err := stream.Send(recordToProto(record)); err != nil {
+			return fmt.Errorf("sending parsed invoice: %w", err)
+		}
+	}
+	return nil
+}
+
+func recordToProto(record Record) *pb.Invoice {
+	return &pb.Invoice{Fields: record}
+}
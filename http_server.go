@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newHTTPServer wires svc onto a mux: POST /invoices, GET /healthz, GET
+// /metrics and POST /templates/reload.
+func newHTTPServer(svc *Service) (*http.ServeMux, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(newServiceCollector(svc)); err != nil {
+		return nil, fmt.Errorf("registering metrics collector: %w", err)
+	}
+	if err := registry.Register(svc.latency); err != nil {
+		return nil, fmt.Errorf("registering latency histogram: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/invoices", handleParseInvoice(svc))
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/templates/reload", handleTemplatesReload(svc))
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return mux, nil
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func handleTemplatesReload(svc *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := svc.Reload(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleParseInvoice(svc *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := readUploadedPDF(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		record, children, err := svc.ParseBytes(data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("parsing PDF: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"invoice": record,
+			"items":   children,
+		})
+	}
+}
+
+// readUploadedPDF accepts either a multipart "file" field or a "url"
+// query/form parameter pointing at a PDF to download over HTTP(S). An
+// S3/GCS URL needs to be turned into a signed HTTPS URL by the caller
+// before it reaches this handler; fetchPDF refuses anything that resolves
+// to a loopback, link-local or other private address, so it can't be used
+// to reach internal services or cloud metadata endpoints.
+func readUploadedPDF(r *http.Request) ([]byte, error) {
+	if url := r.URL.Query().Get("url"); url != "" {
+		return fetchPDF(url)
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err == nil {
+		if url := r.FormValue("url"); url != "" {
+			return fetchPDF(url)
+		}
+		if file, _, err := r.FormFile("file"); err == nil {
+			defer file.Close()
+			return io.ReadAll(file)
+		}
+	}
+
+	return nil, fmt.Errorf(`request must include a multipart "file" field or a "url" query/form parameter`)
+}
+
+// fetchPDFClient dials through safeDialContext so every fetch re-validates
+// the address it actually connects to, not just the URL's hostname.
+var fetchPDFClient = &http.Client{
+	Transport: &http.Transport{DialContext: safeDialContext},
+}
+
+func fetchPDF(rawURL string) ([]byte, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", rawURL, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme %q: only http and https are allowed", parsed.Scheme)
+	}
+
+	resp, err := fetchPDFClient.Get(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: status %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// safeDialContext resolves host, rejects any address that points at the
+// caller's own network (loopback, link-local, private and other
+// non-publicly-routable ranges) and then dials the resolved IP directly, so
+// a DNS answer that changes between the check and the dial (rebinding)
+// can't bypass the check.
+func safeDialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	for _, ip := range ips {
+		if isDisallowedFetchAddr(ip.IP) {
+			return nil, fmt.Errorf("refusing to fetch from disallowed address %s", ip.IP)
+		}
+	}
+
+	dialer := &net.Dialer{}
+	return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+}
+
+func isDisallowedFetchAddr(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsMulticast()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// jsonlStreamWriter writes one JSON object per line, so combined parent +
+// nested-scraper records can be streamed out without buffering the batch.
+type jsonlStreamWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newJSONLStreamWriter(filename string) (*jsonlStreamWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JSONL file %s: %w", filename, err)
+	}
+	return &jsonlStreamWriter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (w *jsonlStreamWriter) WriteLine(v any) error {
+	if err := w.encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write JSONL record: %w", err)
+	}
+	return nil
+}
+
+func (w *jsonlStreamWriter) Close() error {
+	return w.file.Close()
+}
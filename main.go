@@ -2,19 +2,9 @@ package main
 
 import (
 	"bytes"
-	"encoding/csv"
-	"encoding/json"
-	"fmt"
+	"flag"
 	"log"
 	"os"
-	"path/filepath"
-	"regexp"
-	"sort"
-	"strconv"
-	"strings"
-	"sync"
-	"flag"
-	"sync/atomic"
 	"time"
 
 	"github.com/dslipak/pdf"
@@ -22,190 +12,122 @@ import (
 
 // Config holds the configurable parameters for the program.
 type Config struct {
+	InputDir       string
 	OutputFileName string
 	NumWorkers     int
-	TemplatePath   string
-}
-
-// RegexConfig holds the regex patterns loaded from the JSON file.
-type RegexConfig struct {
-	Fatura          string `json:"fatura"`
-	ClienteMatricula string `json:"cliente_matricula"`
-	DataInicio      string `json:"data_inicio"`
-	Valor           string `json:"valor"`
-	PrazoMeses      string `json:"prazo_meses"`
+	MaxInFlight    int
+	TemplateDir    string
+	ScraperDir     string
+	JSONLFileName  string
+	SortMode       SortMode
+	ProgressEvery  time.Duration
+	Output         OutputOptions
+	OCR            OCRConfig
 }
 
-type Invoice struct {
-	Fatura           string
-	ClienteMatricula string
-	DataInicio       string
-	Valor            string
-	PrazoMeses       string
-}
-
-// Global compiled regex patterns for valor cleaning
-var (
-	reValorCleanerDot   = regexp.MustCompile(`\.`)
-	reValorCleanerComma = regexp.MustCompile(`,`)
-)
-
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := runServe(os.Args[2:]); err != nil {
+			log.Fatalf("serve: %v", err)
+		}
+		return
+	}
+
 	startTime := time.Now()
 
 	// Define command-line flags
+	inputDir := flag.String("input-dir", "pdfs", "Directory to scan recursively for PDF files")
 	outputFileName := flag.String("output", "invoices.csv", "Output CSV file name")
 	numWorkers := flag.Int("workers", 8, "Number of worker goroutines")
-	templatePath := flag.String("template", "template.json", "Path to the JSON regex template file")
+	maxInFlight := flag.Int("max-in-flight", 256, "Maximum number of extracted records buffered in the results channel")
+	templateDir := flag.String("template-dir", "templates", "Directory of JSON field-rule templates")
+	scraperDir := flag.String("scraper-dir", "", "Optional directory of JSON scraper rule files, applied to every template")
+	jsonlFileName := flag.String("jsonl-output", "", "Optional combined JSON-lines file with each invoice and its nested scraper rows")
+	sortFlag := flag.String("sort", "none", "Order results before writing: none, fatura or filename (none streams, others buffer everything)")
+	progressEvery := flag.Duration("progress-interval", 5*time.Second, "How often to log throughput/ETA progress (0 disables)")
+	format := flag.String("format", "", "Main output format: csv, jsonl, json, xlsx, sqlite or parquet (defaults to the --output extension)")
+	appendMode := flag.Bool("append", false, "Append to an existing output file instead of truncating it (csv, jsonl, sqlite)")
+	upsertKey := flag.String("upsert-key", "", "Field to upsert on for sqlite output, so re-runs are idempotent")
+	forceOCR := flag.Bool("force-ocr", false, "Always OCR every page instead of only falling back for sparse/empty extracted text")
+	ocrThreshold := flag.Int("ocr-threshold", 20, "Minimum extracted text length before the OCR fallback kicks in")
+	ocrLang := flag.String("ocr-lang", "eng", "Tesseract language(s) to use, e.g. por+eng")
+	ocrDPI := flag.Int("ocr-dpi", 300, "DPI to rasterize pages at before OCR")
+	ocrPages := flag.String("ocr-pages", "", "Page range to OCR, e.g. 1-3 (defaults to every page)")
+	ocrCacheDir := flag.String("ocr-cache-dir", "", "Directory to cache OCR text in, keyed by file SHA-256")
+	ocrRasterizer := flag.String("ocr-rasterizer", "pdftoppm", "Tool used to rasterize PDF pages for OCR: pdftoppm or mutool")
+	ocrRasterizerPath := flag.String("ocr-rasterizer-path", "", "Path to the rasterizer binary (defaults to the tool name on $PATH)")
+	ocrTesseract := flag.String("ocr-tesseract", "binary", "How to run Tesseract: binary (tesseract CLI) or gosseract (cgo binding)")
+	ocrTesseractPath := flag.String("ocr-tesseract-path", "", "Path to the tesseract binary (defaults to tesseract on $PATH)")
 
 	flag.Parse() // Parse the command-line arguments
 
+	sortMode, err := parseSortMode(*sortFlag)
+	if err != nil {
+		log.Fatalf("Invalid --sort value: %v", err)
+	}
+
 	cfg := Config{
+		InputDir:       *inputDir,
 		OutputFileName: *outputFileName,
 		NumWorkers:     *numWorkers,
-		TemplatePath:   *templatePath,
-	}
-
-	// Load regex configuration
-	regexCfg, err := loadRegexConfig(cfg.TemplatePath)
+		MaxInFlight:    *maxInFlight,
+		TemplateDir:    *templateDir,
+		ScraperDir:     *scraperDir,
+		JSONLFileName:  *jsonlFileName,
+		SortMode:       sortMode,
+		ProgressEvery:  *progressEvery,
+		Output: OutputOptions{
+			Format:    *format,
+			Append:    *appendMode,
+			UpsertKey: *upsertKey,
+		},
+		OCR: OCRConfig{
+			Force:          *forceOCR,
+			MinTextLength:  *ocrThreshold,
+			Lang:           *ocrLang,
+			DPI:            *ocrDPI,
+			PageRange:      *ocrPages,
+			CacheDir:       *ocrCacheDir,
+			Rasterizer:     *ocrRasterizer,
+			RasterizerPath: *ocrRasterizerPath,
+			Tesseract:      *ocrTesseract,
+			TesseractPath:  *ocrTesseractPath,
+		},
+	}
+
+	templates, err := loadTemplates(cfg.TemplateDir)
 	if err != nil {
-		log.Fatalf("Failed to load regex configuration from %s: %v", cfg.TemplatePath, err)
+		log.Fatalf("Failed to load templates from %s: %v", cfg.TemplateDir, err)
 	}
+	log.Printf("Loaded %d template(s) from %s", len(templates), cfg.TemplateDir)
 
-	log.Printf("Processing PDFs and writing to %s", cfg.OutputFileName)
-
-	files, err := filepath.Glob("pdfs/*.pdf")
+	globalScrapers, err := loadScrapers(cfg.ScraperDir)
 	if err != nil {
-		log.Fatalf("Failed to find PDF files: %v", err)
-	}
-	log.Printf("Found %d PDF files to process.", len(files))
-
-	jobs := make(chan string, len(files))
-	results := make(chan Invoice, len(files))
-
-	var wg sync.WaitGroup
-	var totalProcessed, totalErrors uint64 // Atomic counters
-
-	for i := 0; i < cfg.NumWorkers; i++ {
-		wg.Add(1)
-		go worker(&wg, jobs, results, &totalProcessed, &totalErrors, regexCfg)
-	}
-
-	for _, file := range files {
-		jobs <- file
-	}
-	close(jobs)
-
-	wg.Wait()
-	close(results)
-
-	// Collect all invoices from the results channel
-	var invoices []Invoice
-	for invoice := range results {
-		invoices = append(invoices, invoice)
+		log.Fatalf("Failed to load scrapers from %s: %v", cfg.ScraperDir, err)
 	}
-
-	// Sort invoices by Fatura number
-	sort.Slice(invoices, func(i, j int) bool {
-		faturaI := invoices[i].Fatura
-		faturaJ := invoices[j].Fatura
-
-		partsI := strings.Split(faturaI, "/")
-		partsJ := strings.Split(faturaJ, "/")
-
-		if len(partsI) < 2 || len(partsJ) < 2 {
-			return faturaI < faturaJ // Fallback to string comparison
-		}
-
-		numI, errI := strconv.Atoi(strings.TrimSpace(partsI[1]))
-		numJ, errJ := strconv.Atoi(strings.TrimSpace(partsJ[1]))
-
-		if errI != nil || errJ != nil {
-			return faturaI < faturaJ // Fallback to string comparison
-		}
-
-		return numI < numJ
-	})
-
-	// Write sorted invoices to CSV
-	if err := WriteInvoicesToCSV(cfg.OutputFileName, invoices); err != nil {
-		log.Fatalf("Failed to write invoices to CSV: %v", err)
+	if len(globalScrapers) > 0 {
+		log.Printf("Loaded %d global scraper rule(s) from %s", len(globalScrapers), cfg.ScraperDir)
 	}
 
-	log.Printf("Processing complete. Processed %d PDFs with %d errors in %s", atomic.LoadUint64(&totalProcessed), atomic.LoadUint64(&totalErrors), time.Since(startTime))
-}
-
-func loadRegexConfig(filePath string) (RegexConfig, error) {
-	var config RegexConfig
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return config, fmt.Errorf("reading regex config file: %w", err)
-	}
-	err = json.Unmarshal(data, &config)
+	fields := append(unionFields(templates), "Source")
+	allScrapers := collectScrapers(templates, globalScrapers)
+	writer, err := newRecordWriter(cfg.OutputFileName, fields, allScrapers, cfg.JSONLFileName, cfg.Output)
 	if err != nil {
-		return config, fmt.Errorf("unmarshalling regex config: %w", err)
-	}
-	return config, nil
-}
-
-func worker(wg *sync.WaitGroup, jobs <-chan string, results chan<- Invoice, totalProcessed, totalErrors *uint64, regexCfg RegexConfig) {
-	defer wg.Done()
-	for job := range jobs {
-		content, err := readPdf(job)
-		if err != nil {
-			log.Printf("Error reading PDF %s: %v", job, err)
-			atomic.AddUint64(totalErrors, 1)
-			continue
-		}
-		invoice := parseInvoice(content, regexCfg)
-		results <- invoice
-		atomic.AddUint64(totalProcessed, 1)
-	}
-}
-
-func parseInvoice(text string, regexCfg RegexConfig) Invoice {
-	invoice := Invoice{}
-
-	reFatura := regexp.MustCompile(regexCfg.Fatura)
-	reCliente := regexp.MustCompile(regexCfg.ClienteMatricula)
-	reMatricula := regexp.MustCompile(`matricula\s+([A-Z0-9]{2}-[A-Z0-9]{2}-[A-Z0-9]{2})`)
-	reDataInicio := regexp.MustCompile(regexCfg.DataInicio)
-	reValor := regexp.MustCompile(regexCfg.Valor)
-	rePrazoMeses := regexp.MustCompile(regexCfg.PrazoMeses)
-
-	match := reFatura.FindStringSubmatch(text)
-	if len(match) > 1 {
-		invoice.Fatura = match[1]
+		log.Fatalf("Failed to open output files: %v", err)
 	}
 
-	clienteMatch := reCliente.FindStringSubmatch(text)
-	matriculaMatch := reMatricula.FindStringSubmatch(text)
-	if len(clienteMatch) > 1 && len(matriculaMatch) > 1 {
-		invoice.ClienteMatricula = fmt.Sprintf("%s/%s", clienteMatch[1], matriculaMatch[1])
-	} else if len(clienteMatch) > 1 {
-		invoice.ClienteMatricula = clienteMatch[1]
-	} else if len(matriculaMatch) > 1 {
-		invoice.ClienteMatricula = matriculaMatch[1]
-	}
-
-	match = reDataInicio.FindStringSubmatch(text)
-	if len(match) > 3 {
-		invoice.DataInicio = fmt.Sprintf("%s-%s-%s", match[3], match[2], match[1])
-	}
+	log.Printf("Processing PDFs under %s and writing to %s (sort=%s)", cfg.InputDir, cfg.OutputFileName, cfg.SortMode)
 
-	match = reValor.FindStringSubmatch(text)
-	if len(match) > 1 {
-		cleanedValor := match[1]
-		cleanedValor = reValorCleanerDot.ReplaceAllString(cleanedValor, "")
-		invoice.Valor = cleanedValor
+	stats, err := runPipeline(cfg.InputDir, templates, globalScrapers, cfg.OCR, cfg.NumWorkers, cfg.MaxInFlight, cfg.SortMode, writer, cfg.ProgressEvery)
+	if err != nil {
+		log.Fatalf("Pipeline failed: %v", err)
 	}
 
-	match = rePrazoMeses.FindStringSubmatch(text)
-	if len(match) > 1 {
-		invoice.PrazoMeses = match[1]
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Failed to finalize %s: %v", cfg.OutputFileName, err)
 	}
 
-	return invoice
+	log.Printf("Processing complete. Processed %d PDFs with %d errors in %s", stats.processed, stats.errors, time.Since(startTime))
 }
 
 func readPdf(path string) (string, error) {
@@ -222,37 +144,3 @@ func readPdf(path string) (string, error) {
 	buf.ReadFrom(b)
 	return buf.String(), nil
 }
-
-// WriteInvoicesToCSV writes a slice of Invoice structs to a CSV file.
-func WriteInvoicesToCSV(filename string, invoices []Invoice) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create CSV file %s: %w", filename, err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write header
-	if err := writer.Write([]string{"Fatura", "Cliente/Matricula", "Data Inicio", "Valor", "Prazo Meses"}); err != nil {
-		return fmt.Errorf("failed to write CSV header: %w", err)
-	}
-
-	// Write invoice data
-	for _, invoice := range invoices {
-		record := []string{
-			invoice.Fatura,
-			invoice.ClienteMatricula,
-			invoice.DataInicio,
-			invoice.Valor,
-			invoice.PrazoMeses,
-		}
-		if err := writer.Write(record); err != nil {
-			return fmt.Errorf("failed to write CSV record: %w", err)
-		}
-	}
-
-	log.Printf("Successfully wrote invoices to %s", filename)
-	return nil
-}
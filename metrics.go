@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// serviceCollector exposes a Service's existing atomic counters as
+// Prometheus metrics, reading them at scrape time instead of keeping a
+// second copy of that state.
+type serviceCollector struct {
+	svc *Service
+
+	processedDesc    *prometheus.Desc
+	errorsDesc       *prometheus.Desc
+	queueDepthDesc   *prometheus.Desc
+	fieldSuccessDesc *prometheus.Desc
+}
+
+func newServiceCollector(svc *Service) *serviceCollector {
+	return &serviceCollector{
+		svc:              svc,
+		processedDesc:    prometheus.NewDesc("pdfcsv_processed_total", "Total PDFs successfully processed.", nil, nil),
+		errorsDesc:       prometheus.NewDesc("pdfcsv_errors_total", "Total PDFs that failed to process.", nil, nil),
+		queueDepthDesc:   prometheus.NewDesc("pdfcsv_worker_queue_depth", "Current number of requests queued but not yet picked up by a worker.", nil, nil),
+		fieldSuccessDesc: prometheus.NewDesc("pdfcsv_field_extraction_success_ratio", "Fraction of processed PDFs where a field was successfully extracted.", []string{"field"}, nil),
+	}
+}
+
+func (c *serviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.processedDesc
+	ch <- c.errorsDesc
+	ch <- c.queueDepthDesc
+	ch <- c.fieldSuccessDesc
+}
+
+func (c *serviceCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.processedDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&c.svc.stats.processed)))
+	ch <- prometheus.MustNewConstMetric(c.errorsDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&c.svc.stats.errors)))
+	ch <- prometheus.MustNewConstMetric(c.queueDepthDesc, prometheus.GaugeValue, float64(c.svc.QueueDepth()))
+
+	c.svc.fieldTotal.Range(func(key, value any) bool {
+		total := atomic.LoadUint64(value.(*uint64))
+		if total == 0 {
+			return true
+		}
+
+		field := key.(string)
+		var hits uint64
+		if v, ok := c.svc.fieldHit.Load(field); ok {
+			hits = atomic.LoadUint64(v.(*uint64))
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.fieldSuccessDesc, prometheus.GaugeValue, float64(hits)/float64(total), field)
+		return true
+	})
+}
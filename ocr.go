@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OCRConfig controls the OCR fallback used when a PDF's embedded text is too
+// sparse (or missing entirely, as with scanned documents) to be useful.
+type OCRConfig struct {
+	Force          bool
+	MinTextLength  int
+	Lang           string
+	DPI            int
+	PageRange      string // e.g. "1-3", empty means all pages
+	CacheDir       string
+	Rasterizer     string // "pdftoppm" or "mutool"
+	RasterizerPath string
+	Tesseract      string // "binary" or "gosseract"
+	TesseractPath  string
+}
+
+// extractedText is the richer result of reading a PDF: the text itself and
+// which pipeline produced it, so downstream users know which rows to trust.
+type extractedText struct {
+	Text   string
+	Source string // "text", "ocr" or "hybrid"
+}
+
+// extractPDFText reads a PDF's embedded text and, when it's too sparse (or
+// ocr.Force is set), falls back to rasterizing the pages and running OCR.
+func extractPDFText(path string, ocr OCRConfig) (extractedText, error) {
+	text, err := readPdf(path)
+	if err != nil {
+		return extractedText{}, err
+	}
+
+	if !ocr.Force && len(strings.TrimSpace(text)) >= ocr.MinTextLength {
+		return extractedText{Text: text, Source: "text"}, nil
+	}
+
+	ocrText, err := ocrPDF(path, ocr)
+	if err != nil {
+		return extractedText{}, fmt.Errorf("OCR fallback for %s: %w", path, err)
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return extractedText{Text: ocrText, Source: "ocr"}, nil
+	}
+	return extractedText{Text: text + "\n" + ocrText, Source: "hybrid"}, nil
+}
+
+// ocrPDF rasterizes path and runs Tesseract over every page, keying the
+// result in ocr.CacheDir by the file's SHA-256 plus the OCR settings used to
+// produce it, so changing --ocr-lang/--ocr-dpi/--ocr-pages busts the cache
+// instead of silently returning text from a previous run's settings.
+func ocrPDF(path string, ocr OCRConfig) (string, error) {
+	hash, err := ocrCacheKey(path, ocr)
+	if err != nil {
+		return "", err
+	}
+
+	if ocr.CacheDir != "" {
+		if cached, ok := readOCRCache(ocr.CacheDir, hash); ok {
+			return cached, nil
+		}
+	}
+
+	images, err := rasterizePDF(path, ocr)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		for _, img := range images {
+			os.Remove(img)
+		}
+	}()
+
+	var sb strings.Builder
+	for _, img := range images {
+		text, err := runTesseract(img, ocr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(text)
+		sb.WriteString("\n")
+	}
+
+	result := sb.String()
+	if ocr.CacheDir != "" {
+		if err := writeOCRCache(ocr.CacheDir, hash, result); err != nil {
+			return "", err
+		}
+	}
+	return result, nil
+}
+
+// rasterizePDF shells out to pdftoppm or mutool to turn each page of path
+// into a PNG, returning the generated file paths in page order.
+func rasterizePDF(path string, ocr OCRConfig) ([]string, error) {
+	tmpDir, err := os.MkdirTemp("", "pdf-csv-ocr-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating OCR temp dir: %w", err)
+	}
+	outPrefix := filepath.Join(tmpDir, "page")
+
+	var cmd *exec.Cmd
+	switch ocr.Rasterizer {
+	case "", "pdftoppm":
+		rasterizerPath := ocr.RasterizerPath
+		if rasterizerPath == "" {
+			rasterizerPath = "pdftoppm"
+		}
+		args := []string{"-r", strconv.Itoa(ocr.DPI), "-png"}
+		if ocr.PageRange != "" {
+			first, last, err := parsePageRange(ocr.PageRange)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "-f", strconv.Itoa(first), "-l", strconv.Itoa(last))
+		}
+		args = append(args, path, outPrefix)
+		cmd = exec.Command(rasterizerPath, args...)
+	case "mutool":
+		rasterizerPath := ocr.RasterizerPath
+		if rasterizerPath == "" {
+			rasterizerPath = "mutool"
+		}
+		// Zero-pad the page number so lexicographic sort.Strings below
+		// matches page order for 10+-page PDFs (pdftoppm does this itself).
+		args := []string{"draw", "-o", outPrefix + "-%04d.png", "-r", strconv.Itoa(ocr.DPI)}
+		args = append(args, path)
+		if ocr.PageRange != "" {
+			args = append(args, ocr.PageRange)
+		}
+		cmd = exec.Command(rasterizerPath, args...)
+	default:
+		return nil, fmt.Errorf("unknown rasterizer %q", ocr.Rasterizer)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running %s: %w: %s", cmd.Path, err, stderr.String())
+	}
+
+	pages, err := filepath.Glob(outPrefix + "*.png")
+	if err != nil {
+		return nil, fmt.Errorf("listing rasterized pages: %w", err)
+	}
+	sort.Strings(pages)
+	return pages, nil
+}
+
+func runTesseract(imagePath string, ocr OCRConfig) (string, error) {
+	switch ocr.Tesseract {
+	case "", "binary":
+		return runTesseractBinary(imagePath, ocr)
+	case "gosseract":
+		return runGosseract(imagePath, ocr)
+	default:
+		return "", fmt.Errorf("unknown tesseract mode %q", ocr.Tesseract)
+	}
+}
+
+func runTesseractBinary(imagePath string, ocr OCRConfig) (string, error) {
+	tesseractPath := ocr.TesseractPath
+	if tesseractPath == "" {
+		tesseractPath = "tesseract"
+	}
+	lang := ocr.Lang
+	if lang == "" {
+		lang = "eng"
+	}
+
+	cmd := exec.Command(tesseractPath, imagePath, "stdout", "-l", lang)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running tesseract: %w: %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+func parsePageRange(pageRange string) (first, last int, err error) {
+	parts := strings.SplitN(pageRange, "-", 2)
+	first, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid page range %q", pageRange)
+	}
+	if len(parts) == 1 {
+		return first, first, nil
+	}
+	last, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid page range %q", pageRange)
+	}
+	return first, last, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ocrCacheKey hashes the file's contents together with every OCR setting
+// that affects the resulting text, so the cache can't return text produced
+// under a different language, DPI or page range.
+func ocrCacheKey(path string, ocr OCRConfig) (string, error) {
+	fileHash, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	io.WriteString(h, fileHash)
+	io.WriteString(h, "|lang=")
+	io.WriteString(h, ocr.Lang)
+	io.WriteString(h, "|dpi=")
+	io.WriteString(h, strconv.Itoa(ocr.DPI))
+	io.WriteString(h, "|pages=")
+	io.WriteString(h, ocr.PageRange)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readOCRCache(cacheDir, hash string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(cacheDir, hash+".txt"))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+func writeOCRCache(cacheDir, hash, text string) error {
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return fmt.Errorf("creating OCR cache dir: %w", err)
+	}
+	return os.WriteFile(filepath.Join(cacheDir, hash+".txt"), []byte(text), 0o644)
+}
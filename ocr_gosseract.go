@@ -0,0 +1,35 @@
+//go:build cgo
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/otiai10/gosseract/v2"
+)
+
+// runGosseract OCRs imagePath via the gosseract cgo binding, as an
+// alternative to shelling out to the tesseract binary.
+func runGosseract(imagePath string, ocr OCRConfig) (string, error) {
+	client := gosseract.NewClient()
+	defer client.Close()
+
+	lang := ocr.Lang
+	if lang == "" {
+		lang = "eng"
+	}
+	if err := client.SetLanguage(strings.Split(lang, "+")...); err != nil {
+		return "", fmt.Errorf("setting gosseract language: %w", err)
+	}
+
+	if err := client.SetImage(imagePath); err != nil {
+		return "", fmt.Errorf("setting gosseract image: %w", err)
+	}
+
+	text, err := client.Text()
+	if err != nil {
+		return "", fmt.Errorf("running gosseract: %w", err)
+	}
+	return text, nil
+}
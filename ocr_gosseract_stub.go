@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package main
+
+import "fmt"
+
+// runGosseract is unavailable in a cgo-disabled build; fall back to an
+// explicit error so callers know to rebuild with CGO_ENABLED=1 or use
+// --ocr-tesseract=binary instead.
+func runGosseract(imagePath string, ocr OCRConfig) (string, error) {
+	return "", fmt.Errorf("gosseract OCR mode requires building with cgo enabled (CGO_ENABLED=1)")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// OutputWriter is implemented by every output backend, so the pipeline can
+// stream records out without knowing which format it's writing.
+type OutputWriter interface {
+	WriteHeader(fields []string) error
+	WriteRecord(fields []string, record Record) error
+	Close() error
+}
+
+// OutputOptions configures the backend returned by newOutputWriter.
+type OutputOptions struct {
+	Format    string // "", csv, jsonl, json, xlsx, sqlite, parquet
+	Append    bool   // csv/jsonl/sqlite: append to an existing file instead of truncating
+	UpsertKey string // sqlite: column to upsert on, for idempotent re-runs
+}
+
+// newOutputWriter selects a backend from opts.Format, falling back to the
+// extension of filename when Format is empty.
+func newOutputWriter(filename string, opts OutputOptions) (OutputWriter, error) {
+	format := opts.Format
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+
+	switch format {
+	case "csv", "":
+		return newCSVOutputWriter(filename, opts.Append)
+	case "jsonl":
+		return newJSONLOutputWriter(filename, opts.Append)
+	case "json":
+		return newJSONArrayWriter(filename)
+	case "xlsx":
+		return newXLSXWriter(filename)
+	case "sqlite", "db":
+		return newSQLiteWriter(filename, opts.Append, opts.UpsertKey)
+	case "parquet":
+		return newParquetWriter(filename)
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+}
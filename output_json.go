@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// jsonArrayWriter streams Records out as a single JSON array, writing the
+// brackets and commas by hand so it never has to hold the whole batch in
+// memory to know where the array ends.
+type jsonArrayWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+	wrote   bool
+}
+
+func newJSONArrayWriter(filename string) (*jsonArrayWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JSON file %s: %w", filename, err)
+	}
+	if _, err := file.WriteString("[\n"); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write JSON array opening: %w", err)
+	}
+	return &jsonArrayWriter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (w *jsonArrayWriter) WriteHeader(fields []string) error {
+	return nil
+}
+
+func (w *jsonArrayWriter) WriteRecord(fields []string, record Record) error {
+	if w.wrote {
+		if _, err := w.file.WriteString(","); err != nil {
+			return fmt.Errorf("failed to write JSON array separator: %w", err)
+		}
+	}
+	w.wrote = true
+	if err := w.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to write JSON record: %w", err)
+	}
+	return nil
+}
+
+func (w *jsonArrayWriter) Close() error {
+	if _, err := w.file.WriteString("]\n"); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to write JSON array closing: %w", err)
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// jsonlOutputWriter writes one JSON object per Record, one line at a time.
+type jsonlOutputWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newJSONLOutputWriter(filename string, appendMode bool) (*jsonlOutputWriter, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open JSONL file %s: %w", filename, err)
+	}
+	return &jsonlOutputWriter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// WriteHeader is a no-op: JSONL objects are self-describing, so there is no
+// separate header row.
+func (w *jsonlOutputWriter) WriteHeader(fields []string) error {
+	return nil
+}
+
+func (w *jsonlOutputWriter) WriteRecord(fields []string, record Record) error {
+	if err := w.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to write JSONL record: %w", err)
+	}
+	return nil
+}
+
+func (w *jsonlOutputWriter) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetWriter writes Records as a flat, all-string-column parquet file.
+// The schema is only known once WriteHeader is called with the resolved
+// field list, since parquet-go needs it up front to build its column
+// writers.
+type parquetWriter struct {
+	file   source.ParquetFile
+	writer *writer.JSONWriter
+}
+
+func newParquetWriter(filename string) (*parquetWriter, error) {
+	file, err := local.NewLocalFileWriter(filename)
+	if err != nil {
+		return nil, fmt.Errorf("creating parquet file %s: %w", filename, err)
+	}
+	return &parquetWriter{file: file}, nil
+}
+
+func (w *parquetWriter) WriteHeader(fields []string) error {
+	pw, err := writer.NewJSONWriter(parquetSchema(fields), w.file, 4)
+	if err != nil {
+		return fmt.Errorf("creating parquet writer: %w", err)
+	}
+	w.writer = pw
+	return nil
+}
+
+func (w *parquetWriter) WriteRecord(fields []string, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshalling parquet row: %w", err)
+	}
+	if err := w.writer.Write(string(data)); err != nil {
+		return fmt.Errorf("writing parquet row: %w", err)
+	}
+	return nil
+}
+
+func (w *parquetWriter) Close() error {
+	if err := w.writer.WriteStop(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("flushing parquet writer: %w", err)
+	}
+	return w.file.Close()
+}
+
+// parquetSchema builds a parquet-go JSON schema with every field as an
+// optional UTF8 byte array column, matching the untyped, all-string Record
+// type every backend writes.
+func parquetSchema(fields []string) string {
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = fmt.Sprintf(`{"Tag":"name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"}`, field)
+	}
+	return fmt.Sprintf(`{"Tag":"name=record, repetitiontype=REQUIRED","Fields":[%s]}`, strings.Join(columns, ","))
+}
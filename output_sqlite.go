@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteTableName = "invoices"
+
+// sqliteWriter auto-creates a table from the record schema and inserts one
+// row per record. When upsertKey is set, re-running against the same
+// database file updates existing rows instead of duplicating them, except
+// for records missing the key: those would all collide on the empty string
+// and collapse into one row, so they fall back to a plain insert instead.
+type sqliteWriter struct {
+	db         *sql.DB
+	insertStmt *sql.Stmt // plain INSERT, always used when upsertKey == "" or a record's key is empty
+	upsertStmt *sql.Stmt // INSERT ... ON CONFLICT DO UPDATE, used when upsertKey is set and present
+	upsertKey  string
+}
+
+func newSQLiteWriter(filename string, appendMode bool, upsertKey string) (*sqliteWriter, error) {
+	if !appendMode {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("removing existing sqlite file %s: %w", filename, err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite file %s: %w", filename, err)
+	}
+	return &sqliteWriter{db: db, upsertKey: upsertKey}, nil
+}
+
+func (w *sqliteWriter) WriteHeader(fields []string) error {
+	columns := make([]string, len(fields))
+	quotedCols := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = fmt.Sprintf("%q TEXT", field)
+		quotedCols[i] = fmt.Sprintf("%q", field)
+	}
+
+	ddl := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (%s)`, sqliteTableName, strings.Join(columns, ", "))
+	if _, err := w.db.Exec(ddl); err != nil {
+		return fmt.Errorf("creating sqlite table: %w", err)
+	}
+
+	placeholders := make([]string, len(fields))
+	for i := range fields {
+		placeholders[i] = "?"
+	}
+
+	plainInsertSQL := fmt.Sprintf(`INSERT INTO %q (%s) VALUES (%s)`,
+		sqliteTableName, strings.Join(quotedCols, ", "), strings.Join(placeholders, ", "))
+	stmt, err := w.db.Prepare(plainInsertSQL)
+	if err != nil {
+		return fmt.Errorf("preparing sqlite insert: %w", err)
+	}
+	w.insertStmt = stmt
+
+	if w.upsertKey != "" {
+		idxSQL := fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %q ON %q (%q)`,
+			sqliteTableName+"_"+w.upsertKey+"_idx", sqliteTableName, w.upsertKey)
+		if _, err := w.db.Exec(idxSQL); err != nil {
+			return fmt.Errorf("creating upsert-key index: %w", err)
+		}
+
+		updates := make([]string, 0, len(fields))
+		for _, field := range fields {
+			if field == w.upsertKey {
+				continue
+			}
+			updates = append(updates, fmt.Sprintf("%q = excluded.%q", field, field))
+		}
+		upsertSQL := fmt.Sprintf(`INSERT INTO %q (%s) VALUES (%s) ON CONFLICT(%q) DO UPDATE SET %s`,
+			sqliteTableName, strings.Join(quotedCols, ", "), strings.Join(placeholders, ", "), w.upsertKey, strings.Join(updates, ", "))
+		upsertStmt, err := w.db.Prepare(upsertSQL)
+		if err != nil {
+			return fmt.Errorf("preparing sqlite upsert: %w", err)
+		}
+		w.upsertStmt = upsertStmt
+	}
+
+	return nil
+}
+
+func (w *sqliteWriter) WriteRecord(fields []string, record Record) error {
+	args := make([]any, len(fields))
+	for i, field := range fields {
+		args[i] = record[field]
+	}
+
+	// A record missing the upsert key would upsert on the empty string,
+	// colliding with every other record missing that key and collapsing
+	// them into a single row. Fall back to a plain insert for those, with
+	// the key stored as NULL so the unique index doesn't reject them.
+	stmt := w.insertStmt
+	if w.upsertStmt != nil {
+		if record[w.upsertKey] != "" {
+			stmt = w.upsertStmt
+		} else {
+			for i, field := range fields {
+				if field == w.upsertKey {
+					args[i] = nil
+				}
+			}
+		}
+	}
+
+	if _, err := stmt.Exec(args...); err != nil {
+		return fmt.Errorf("inserting sqlite row: %w", err)
+	}
+	return nil
+}
+
+func (w *sqliteWriter) Close() error {
+	if w.upsertStmt != nil {
+		if err := w.upsertStmt.Close(); err != nil {
+			w.db.Close()
+			return fmt.Errorf("closing sqlite statement: %w", err)
+		}
+	}
+	if w.insertStmt != nil {
+		if err := w.insertStmt.Close(); err != nil {
+			w.db.Close()
+			return fmt.Errorf("closing sqlite statement: %w", err)
+		}
+	}
+	return w.db.Close()
+}
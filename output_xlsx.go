@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxWriter buffers rows into a single sheet and saves the workbook on
+// Close. excelize keeps the whole workbook in memory regardless, so this
+// backend doesn't give the O(workers) memory behaviour the streaming
+// formats do; that's an inherent limitation of the xlsx format, not this
+// writer.
+type xlsxWriter struct {
+	filename string
+	file     *excelize.File
+	sheet    string
+	row      int
+}
+
+const xlsxSheetName = "Invoices"
+
+func newXLSXWriter(filename string) (*xlsxWriter, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName(f.GetSheetName(0), xlsxSheetName); err != nil {
+		return nil, fmt.Errorf("renaming default sheet: %w", err)
+	}
+	return &xlsxWriter{filename: filename, file: f, sheet: xlsxSheetName, row: 1}, nil
+}
+
+func (w *xlsxWriter) WriteHeader(fields []string) error {
+	for i, field := range fields {
+		cell, err := excelize.CoordinatesToCellName(i+1, w.row)
+		if err != nil {
+			return fmt.Errorf("computing header cell: %w", err)
+		}
+		if err := w.file.SetCellValue(w.sheet, cell, field); err != nil {
+			return fmt.Errorf("writing xlsx header: %w", err)
+		}
+	}
+	w.row++
+	return nil
+}
+
+func (w *xlsxWriter) WriteRecord(fields []string, record Record) error {
+	for i, field := range fields {
+		cell, err := excelize.CoordinatesToCellName(i+1, w.row)
+		if err != nil {
+			return fmt.Errorf("computing record cell: %w", err)
+		}
+		if err := w.file.SetCellValue(w.sheet, cell, record[field]); err != nil {
+			return fmt.Errorf("writing xlsx record: %w", err)
+		}
+	}
+	w.row++
+	return nil
+}
+
+func (w *xlsxWriter) Close() error {
+	if err := w.file.SaveAs(w.filename); err != nil {
+		return fmt.Errorf("saving xlsx file %s: %w", w.filename, err)
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SortMode controls how (and whether) extracted records are ordered before
+// they are written out. Sorting requires buffering every record in memory,
+// so the default is SortNone to keep large batches streaming.
+type SortMode string
+
+const (
+	SortNone     SortMode = "none"
+	SortFatura   SortMode = "fatura"
+	SortFilename SortMode = "filename"
+)
+
+func parseSortMode(s string) (SortMode, error) {
+	switch SortMode(s) {
+	case SortNone, SortFatura, SortFilename:
+		return SortMode(s), nil
+	default:
+		return "", fmt.Errorf("unknown sort mode %q (want none, fatura or filename)", s)
+	}
+}
+
+type pipelineResult struct {
+	path     string
+	record   Record
+	children map[string][]Record
+}
+
+type pipelineStats struct {
+	processed uint64
+	errors    uint64
+}
+
+// runPipeline walks root lazily, fans PDFs out to numWorkers workers and
+// streams the resulting records into writer. Jobs and results both flow
+// through bounded channels, so memory stays O(workers) rather than O(number
+// of PDFs) whenever sortMode is SortNone.
+func runPipeline(root string, templates []*Template, globalScrapers []*ScraperRule, ocr OCRConfig, numWorkers, maxInFlight int, sortMode SortMode, writer *recordWriter, progressEvery time.Duration) (*pipelineStats, error) {
+	stats := &pipelineStats{}
+
+	total, err := countPDFs(root)
+	if err != nil {
+		return nil, fmt.Errorf("counting PDF files under %s: %w", root, err)
+	}
+	log.Printf("Found %d PDF files under %s", total, root)
+
+	jobs := make(chan string, numWorkers*2)
+	results := make(chan pipelineResult, maxInFlight)
+
+	stopProgress := make(chan struct{})
+	var progressWG sync.WaitGroup
+	if progressEvery > 0 {
+		progressWG.Add(1)
+		go func() {
+			defer progressWG.Done()
+			reportProgress(stats, total, progressEvery, stopProgress)
+		}()
+	}
+
+	var workersWG sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		workersWG.Add(1)
+		go func() {
+			defer workersWG.Done()
+			pipelineWorker(jobs, results, stats, templates, globalScrapers, ocr)
+		}()
+	}
+
+	var walkErr error
+	go func() {
+		walkErr = walkPDFs(root, jobs)
+		close(jobs)
+	}()
+
+	go func() {
+		workersWG.Wait()
+		close(results)
+	}()
+
+	if err := writer.WriteHeader(); err != nil {
+		return nil, fmt.Errorf("writing output header: %w", err)
+	}
+
+	if sortMode == SortNone {
+		for res := range results {
+			if err := writer.WriteResult(res.path, res.record, res.children); err != nil {
+				return nil, fmt.Errorf("writing record for %s: %w", res.path, err)
+			}
+		}
+	} else {
+		var collected []pipelineResult
+		for res := range results {
+			collected = append(collected, res)
+		}
+		sortResults(collected, sortMode)
+		for _, res := range collected {
+			if err := writer.WriteResult(res.path, res.record, res.children); err != nil {
+				return nil, fmt.Errorf("writing record for %s: %w", res.path, err)
+			}
+		}
+	}
+
+	close(stopProgress)
+	progressWG.Wait()
+
+	return stats, walkErr
+}
+
+// walkPDFs lazily enumerates every *.pdf file under root and feeds the paths
+// into jobs, so the whole tree never has to be held in memory at once.
+func walkPDFs(root string, jobs chan<- string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".pdf") {
+			return nil
+		}
+		jobs <- path
+		return nil
+	})
+}
+
+// countPDFs does a cheap pre-pass over root to size progress reporting. It
+// only keeps a running count, not the paths themselves.
+func countPDFs(root string) (int, error) {
+	count := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".pdf") {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func pipelineWorker(jobs <-chan string, results chan<- pipelineResult, stats *pipelineStats, templates []*Template, globalScrapers []*ScraperRule, ocr OCRConfig) {
+	for path := range jobs {
+		extracted, err := extractPDFText(path, ocr)
+		if err != nil {
+			log.Printf("Error reading PDF %s: %v", path, err)
+			atomic.AddUint64(&stats.errors, 1)
+			continue
+		}
+		content := extracted.Text
+
+		_, record, children, err := processDocument(content, templates, globalScrapers)
+		if err != nil {
+			log.Printf("Error selecting template for %s: %v", path, err)
+			atomic.AddUint64(&stats.errors, 1)
+			continue
+		}
+		record["Source"] = extracted.Source
+
+		results <- pipelineResult{path: path, record: record, children: children}
+		atomic.AddUint64(&stats.processed, 1)
+	}
+}
+
+func sortResults(results []pipelineResult, mode SortMode) {
+	switch mode {
+	case SortFatura:
+		sort.Slice(results, func(i, j int) bool {
+			return faturaSortKey(results[i].record["Fatura"]) < faturaSortKey(results[j].record["Fatura"])
+		})
+	case SortFilename:
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].path < results[j].path
+		})
+	}
+}
+
+// faturaSortKey extracts the numeric suffix of a "NNNN/YYYY"-style fatura
+// number, zero-padded, so invoices sort numerically rather than
+// lexicographically.
+func faturaSortKey(fatura string) string {
+	parts := strings.Split(fatura, "/")
+	if len(parts) < 2 {
+		return fatura
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return fatura
+	}
+	return fmt.Sprintf("%020d", n)
+}
+
+// reportProgress logs throughput, ETA and rolling error rate every `every`
+// until stop is closed.
+func reportProgress(stats *pipelineStats, total int, every time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	var lastProcessed, lastErrors uint64
+	lastTick := time.Now()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			processed := atomic.LoadUint64(&stats.processed)
+			errors := atomic.LoadUint64(&stats.errors)
+
+			elapsed := now.Sub(lastTick).Seconds()
+			rate := float64(processed-lastProcessed) / elapsed
+			intervalErrors := errors - lastErrors
+			intervalDone := (processed - lastProcessed) + intervalErrors
+			lastProcessed = processed
+			lastErrors = errors
+			lastTick = now
+
+			var eta time.Duration
+			if rate > 0 && total > int(processed) {
+				eta = time.Duration(float64(total-int(processed))/rate) * time.Second
+			}
+
+			var errRate float64
+			if intervalDone > 0 {
+				errRate = float64(intervalErrors) / float64(intervalDone) * 100
+			}
+
+			log.Printf("Progress: %d/%d processed, %d errors (%.1f%% over the last %s), %.1f PDFs/s, ETA %s",
+				processed, total, errors, errRate, every, rate, eta.Round(time.Second))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// scraperOutputPath turns "invoices.csv" + "items" into "invoices.items.csv".
+// Scraper outputs are always CSV (newCSVStreamWriter), regardless of the
+// main output's format, so the extension is forced to ".csv" rather than
+// copied from outputFileName — otherwise "--output out.json" would produce
+// a CSV file misleadingly named "out.items.json".
+func scraperOutputPath(outputFileName, scraperName string) string {
+	ext := filepath.Ext(outputFileName)
+	base := strings.TrimSuffix(outputFileName, ext)
+	return fmt.Sprintf("%s.%s.csv", base, scraperName)
+}
+
+// recordWriter fans each PDF's extraction out to the main output, one CSV per
+// scraper (linked back to the parent by source_pdf/row_index), and an
+// optional combined JSON-lines file with the parent record plus nested
+// scraper arrays.
+type recordWriter struct {
+	parentFields []string
+	parent       OutputWriter
+
+	scraperFields map[string][]string
+	scraperNames  []string
+	scraperCSVs   map[string]*csvStreamWriter
+
+	jsonl *jsonlStreamWriter
+}
+
+// newRecordWriter opens the main output at outputFileName (format/append/
+// upsert chosen via opts), one "<base>.<scraper>.csv" per scraper name, and
+// jsonlFileName when non-empty. Scraper outputs are always CSV; opts only
+// governs the main output.
+func newRecordWriter(outputFileName string, parentFields []string, scrapers []*ScraperRule, jsonlFileName string, opts OutputOptions) (*recordWriter, error) {
+	parent, err := newOutputWriter(outputFileName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rw := &recordWriter{
+		parentFields:  parentFields,
+		parent:        parent,
+		scraperNames:  scraperNames(scrapers),
+		scraperFields: make(map[string][]string),
+		scraperCSVs:   make(map[string]*csvStreamWriter),
+	}
+
+	for _, name := range rw.scraperNames {
+		rw.scraperFields[name] = scraperFieldNames(scrapers, name)
+		path := scraperOutputPath(outputFileName, name)
+		writer, err := newCSVStreamWriter(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening output for scraper %q: %w", name, err)
+		}
+		rw.scraperCSVs[name] = writer
+	}
+
+	if jsonlFileName != "" {
+		rw.jsonl, err = newJSONLStreamWriter(jsonlFileName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rw, nil
+}
+
+// WriteHeader writes the header of the main output and every scraper CSV.
+func (rw *recordWriter) WriteHeader() error {
+	if err := rw.parent.WriteHeader(rw.parentFields); err != nil {
+		return err
+	}
+	for _, name := range rw.scraperNames {
+		columns := append([]string{"source_pdf", "row_index"}, rw.scraperFields[name]...)
+		if err := rw.scraperCSVs[name].WriteHeader(columns); err != nil {
+			return fmt.Errorf("writing header for scraper %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// WriteResult writes the parent record, its scraper rows and, if enabled,
+// a combined JSONL line for one PDF.
+func (rw *recordWriter) WriteResult(sourcePdf string, parent Record, children map[string][]Record) error {
+	if err := rw.parent.WriteRecord(rw.parentFields, parent); err != nil {
+		return err
+	}
+
+	for _, name := range rw.scraperNames {
+		fields := rw.scraperFields[name]
+		columns := append([]string{"source_pdf", "row_index"}, fields...)
+		for i, row := range children[name] {
+			record := make(Record, len(row)+2)
+			record["source_pdf"] = sourcePdf
+			record["row_index"] = fmt.Sprintf("%d", i)
+			for k, v := range row {
+				record[k] = v
+			}
+			if err := rw.scraperCSVs[name].WriteRecord(columns, record); err != nil {
+				return fmt.Errorf("writing row for scraper %q: %w", name, err)
+			}
+		}
+	}
+
+	if rw.jsonl != nil {
+		line := map[string]any{
+			"source_pdf": sourcePdf,
+			"invoice":    parent,
+			"items":      children,
+		}
+		if err := rw.jsonl.WriteLine(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rw *recordWriter) Close() error {
+	if err := rw.parent.Close(); err != nil {
+		return err
+	}
+	for _, name := range rw.scraperNames {
+		if err := rw.scraperCSVs[name].Close(); err != nil {
+			return fmt.Errorf("closing output for scraper %q: %w", name, err)
+		}
+	}
+	if rw.jsonl != nil {
+		return rw.jsonl.Close()
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ScraperSection narrows a scraper to the text between two delimiting
+// regexes instead of the whole document. Both ends are optional; an empty
+// Begin/End means "start of text" / "end of text" respectively.
+type ScraperSection struct {
+	Begin string `json:"begin"`
+	End   string `json:"end"`
+}
+
+// ScraperField names a capture group pulled out of every row a scraper
+// matches.
+type ScraperField struct {
+	Name      string `json:"name"`
+	Group     int    `json:"group"`      // capture group index, defaults to 1
+	GroupName string `json:"group_name"` // named capture group, takes precedence over Group
+}
+
+// ScraperRule extracts zero or more repeated rows (e.g. invoice line items)
+// out of a PDF's text, as opposed to FieldRule which extracts a single
+// value per document.
+type ScraperRule struct {
+	Name    string          `json:"name"`
+	Type    string          `json:"type"` // "regex" or "multiline-regex"
+	Pattern string          `json:"pattern"`
+	Section *ScraperSection `json:"section"`
+	Fields  []ScraperField  `json:"fields"`
+
+	compiled *regexp.Regexp
+	beginRe  *regexp.Regexp
+	endRe    *regexp.Regexp
+}
+
+func compileScraper(rule *ScraperRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("scraper has no name")
+	}
+	if len(rule.Fields) == 0 {
+		return fmt.Errorf("scraper %q has no fields", rule.Name)
+	}
+
+	pattern := rule.Pattern
+	switch rule.Type {
+	case "", "regex":
+		rule.Type = "regex"
+	case "multiline-regex":
+		pattern = "(?s)" + pattern
+	default:
+		return fmt.Errorf("scraper %q has unknown type %q", rule.Name, rule.Type)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("compiling pattern for scraper %q: %w", rule.Name, err)
+	}
+	rule.compiled = re
+
+	if rule.Section != nil {
+		if rule.Section.Begin != "" {
+			rule.beginRe, err = regexp.Compile(rule.Section.Begin)
+			if err != nil {
+				return fmt.Errorf("compiling section begin for scraper %q: %w", rule.Name, err)
+			}
+		}
+		if rule.Section.End != "" {
+			rule.endRe, err = regexp.Compile(rule.Section.End)
+			if err != nil {
+				return fmt.Errorf("compiling section end for scraper %q: %w", rule.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadScrapers reads and compiles every *.json scraper rule file in dir, so
+// users can drop in new scrapers without editing a template.
+func loadScrapers(dir string) ([]*ScraperRule, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("globbing scrapers in %s: %w", dir, err)
+	}
+
+	rules := make([]*ScraperRule, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading scraper file %s: %w", path, err)
+		}
+		var rule ScraperRule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return nil, fmt.Errorf("unmarshalling scraper file %s: %w", path, err)
+		}
+		if err := compileScraper(&rule); err != nil {
+			return nil, fmt.Errorf("loading scraper file %s: %w", path, err)
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, nil
+}
+
+// section returns the slice of text a scraper should run against, narrowed
+// to the part between its Begin and End delimiters when configured.
+func (rule *ScraperRule) section(text string) string {
+	if rule.Section == nil {
+		return text
+	}
+
+	start := 0
+	if rule.beginRe != nil {
+		if loc := rule.beginRe.FindStringIndex(text); loc != nil {
+			start = loc[1]
+		}
+	}
+
+	end := len(text)
+	if rule.endRe != nil {
+		if loc := rule.endRe.FindStringIndex(text[start:]); loc != nil {
+			end = start + loc[0]
+		}
+	}
+
+	if start >= end {
+		return ""
+	}
+	return text[start:end]
+}
+
+// scrape runs rule against text and returns one Record per matched row.
+func (rule *ScraperRule) scrape(text string) []Record {
+	section := rule.section(text)
+	if section == "" {
+		return nil
+	}
+
+	matches := rule.compiled.FindAllStringSubmatch(section, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	rows := make([]Record, 0, len(matches))
+	for _, match := range matches {
+		row := make(Record, len(rule.Fields))
+		for _, field := range rule.Fields {
+			groupIdx := field.Group
+			if field.GroupName != "" {
+				groupIdx = rule.compiled.SubexpIndex(field.GroupName)
+				if groupIdx < 0 {
+					continue
+				}
+			} else if groupIdx == 0 {
+				groupIdx = 1
+			}
+			if groupIdx >= len(match) {
+				continue
+			}
+			row[field.Name] = strings.TrimSpace(match[groupIdx])
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// runScrapers runs every scraper against text and returns the rows it
+// produced, keyed by scraper name.
+func runScrapers(text string, scrapers []*ScraperRule) map[string][]Record {
+	if len(scrapers) == 0 {
+		return nil
+	}
+
+	results := make(map[string][]Record, len(scrapers))
+	for _, rule := range scrapers {
+		rows := rule.scrape(text)
+		if len(rows) == 0 {
+			continue
+		}
+		results[rule.Name] = rows
+	}
+	return results
+}
+
+// scraperFieldNames returns the union of field names declared across
+// scrapers sharing a name (templates may repeat a scraper with the same
+// name but different patterns per layout), in first-seen order.
+func scraperFieldNames(scrapers []*ScraperRule, name string) []string {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, rule := range scrapers {
+		if rule.Name != name {
+			continue
+		}
+		for _, field := range rule.Fields {
+			if seen[field.Name] {
+				continue
+			}
+			seen[field.Name] = true
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields
+}
+
+// collectScrapers flattens every template's inline scrapers together with
+// the global scrapers loaded from --scraper-dir, for schema purposes (e.g.
+// deriving the set of output files and their columns up front).
+func collectScrapers(templates []*Template, global []*ScraperRule) []*ScraperRule {
+	rules := make([]*ScraperRule, 0, len(global))
+	for _, tmpl := range templates {
+		for i := range tmpl.Scrapers {
+			rules = append(rules, &tmpl.Scrapers[i])
+		}
+	}
+	rules = append(rules, global...)
+	return rules
+}
+
+// scraperNames returns the distinct scraper names declared across rules, in
+// first-seen order.
+func scraperNames(scrapers []*ScraperRule) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, rule := range scrapers {
+		if seen[rule.Name] {
+			continue
+		}
+		seen[rule.Name] = true
+		names = append(names, rule.Name)
+	}
+	return names
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runServe starts the long-running HTTP and gRPC front ends backed by a
+// single Service, so a caller can submit PDFs one at a time instead of
+// pointing the batch pipeline at a directory. The gRPC front end requires
+// building with -tags grpc against a pdfcsvpb package generated by `make
+// proto`; without that tag, startGRPC (grpc_disabled.go) is a no-op and
+// only the HTTP front end runs.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	templateDir := fs.String("template-dir", "templates", "Directory of JSON field-rule templates")
+	scraperDir := fs.String("scraper-dir", "", "Optional directory of JSON scraper rule files, applied to every template")
+	numWorkers := fs.Int("workers", 8, "Number of warm worker goroutines")
+	queueDepth := fs.Int("queue-depth", 256, "Maximum number of requests buffered ahead of the worker pool")
+	httpAddr := fs.String("http-addr", ":8080", "Address to serve HTTP (POST /invoices, GET /healthz, GET /metrics) on")
+	grpcAddr := fs.String("grpc-addr", ":9090", "Address to serve the PdfCsv gRPC service on (requires building with -tags grpc)")
+	forceOCR := fs.Bool("force-ocr", false, "Always OCR every page instead of only falling back for sparse/empty extracted text")
+	ocrThreshold := fs.Int("ocr-threshold", 20, "Minimum extracted text length before the OCR fallback kicks in")
+	ocrLang := fs.String("ocr-lang", "eng", "Tesseract language(s) to use, e.g. por+eng")
+	ocrDPI := fs.Int("ocr-dpi", 300, "DPI to rasterize pages at before OCR")
+	ocrCacheDir := fs.String("ocr-cache-dir", "", "Directory to cache OCR text in, keyed by file SHA-256")
+	ocrRasterizer := fs.String("ocr-rasterizer", "pdftoppm", "Tool used to rasterize PDF pages for OCR: pdftoppm or mutool")
+	ocrTesseract := fs.String("ocr-tesseract", "binary", "How to run Tesseract: binary (tesseract CLI) or gosseract (cgo binding)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ocr := OCRConfig{
+		Force:         *forceOCR,
+		MinTextLength: *ocrThreshold,
+		Lang:          *ocrLang,
+		DPI:           *ocrDPI,
+		CacheDir:      *ocrCacheDir,
+		Rasterizer:    *ocrRasterizer,
+		Tesseract:     *ocrTesseract,
+	}
+
+	svc, err := newService(*templateDir, *scraperDir, ocr, *numWorkers, *queueDepth)
+	if err != nil {
+		return fmt.Errorf("starting service: %w", err)
+	}
+
+	mux, err := newHTTPServer(svc)
+	if err != nil {
+		return fmt.Errorf("building HTTP server: %w", err)
+	}
+	httpServer := &http.Server{Addr: *httpAddr, Handler: mux}
+
+	stopGRPC, err := startGRPC(svc, *grpcAddr)
+	if err != nil {
+		return fmt.Errorf("starting gRPC server: %w", err)
+	}
+
+	go func() {
+		log.Printf("HTTP server listening on %s", *httpAddr)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("HTTP server failed: %v", err)
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+
+	for s := range sig {
+		if s == syscall.SIGHUP {
+			if err := svc.Reload(); err != nil {
+				log.Printf("Reload failed: %v", err)
+			}
+			continue
+		}
+		break
+	}
+
+	log.Printf("Shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	httpServer.Shutdown(ctx)
+	stopGRPC()
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// templateSet bundles the templates and scrapers active at a point in time,
+// so a hot reload can swap the whole set in one atomic step.
+type templateSet struct {
+	templates []*Template
+	scrapers  []*ScraperRule
+}
+
+// Service keeps a warm worker pool and the current template set alive
+// across many requests, backing both the HTTP and gRPC front ends of the
+// serve subcommand. It reuses the same pipelineStats counters the batch
+// pipeline uses, so metrics never drift between the two modes.
+type Service struct {
+	mu  sync.RWMutex
+	set *templateSet
+
+	templateDir string
+	scraperDir  string
+	ocr         OCRConfig
+
+	stats      *pipelineStats
+	fieldTotal sync.Map // field name -> *uint64
+	fieldHit   sync.Map // field name -> *uint64
+	latency    prometheus.Histogram
+
+	jobs chan serviceJob
+}
+
+type serviceJob struct {
+	content string
+	result  chan serviceResult
+}
+
+type serviceResult struct {
+	tmpl     *Template
+	record   Record
+	children map[string][]Record
+	err      error
+}
+
+// newService loads the initial template set and starts numWorkers workers
+// draining a queue of depth queueDepth.
+func newService(templateDir, scraperDir string, ocr OCRConfig, numWorkers, queueDepth int) (*Service, error) {
+	svc := &Service{
+		templateDir: templateDir,
+		scraperDir:  scraperDir,
+		ocr:         ocr,
+		stats:       &pipelineStats{},
+		jobs:        make(chan serviceJob, queueDepth),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "pdfcsv_extraction_duration_seconds",
+			Help:    "Time spent extracting a single PDF, from template selection through field extraction.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+
+	if err := svc.Reload(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < numWorkers; i++ {
+		go svc.worker()
+	}
+
+	return svc, nil
+}
+
+// Reload re-reads templates and scrapers from disk and swaps them in
+// atomically. In-flight requests keep using the set they started with.
+func (s *Service) Reload() error {
+	templates, err := loadTemplates(s.templateDir)
+	if err != nil {
+		return fmt.Errorf("reloading templates: %w", err)
+	}
+	scrapers, err := loadScrapers(s.scraperDir)
+	if err != nil {
+		return fmt.Errorf("reloading scrapers: %w", err)
+	}
+
+	s.mu.Lock()
+	s.set = &templateSet{templates: templates, scrapers: scrapers}
+	s.mu.Unlock()
+
+	log.Printf("Reloaded %d template(s) and %d global scraper(s)", len(templates), len(scrapers))
+	return nil
+}
+
+func (s *Service) currentSet() *templateSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set
+}
+
+func (s *Service) worker() {
+	for job := range s.jobs {
+		start := time.Now()
+		set := s.currentSet()
+		tmpl, record, children, err := processDocument(job.content, set.templates, set.scrapers)
+		s.latency.Observe(time.Since(start).Seconds())
+
+		if err != nil {
+			atomic.AddUint64(&s.stats.errors, 1)
+		} else {
+			atomic.AddUint64(&s.stats.processed, 1)
+			s.recordFieldSuccess(tmpl, record)
+		}
+
+		job.result <- serviceResult{tmpl: tmpl, record: record, children: children, err: err}
+	}
+}
+
+// ParseBytes extracts text from a raw PDF and runs it through the current
+// template set, queueing the work on the shared worker pool.
+func (s *Service) ParseBytes(data []byte) (Record, map[string][]Record, error) {
+	tmpFile, err := os.CreateTemp("", "pdf-csv-serve-*.pdf")
+	if err != nil {
+		return nil, nil, fmt.Errorf("buffering upload: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		return nil, nil, fmt.Errorf("buffering upload: %w", err)
+	}
+
+	extracted, err := extractPDFText(tmpFile.Name(), s.ocr)
+	if err != nil {
+		atomic.AddUint64(&s.stats.errors, 1)
+		return nil, nil, err
+	}
+
+	job := serviceJob{content: extracted.Text, result: make(chan serviceResult, 1)}
+	s.jobs <- job
+	res := <-job.result
+	if res.err != nil {
+		return nil, nil, res.err
+	}
+
+	res.record["Source"] = extracted.Source
+	return res.record, res.children, nil
+}
+
+// QueueDepth reports how many requests are queued but not yet picked up by
+// a worker, for the worker_queue_depth metric.
+func (s *Service) QueueDepth() int {
+	return len(s.jobs)
+}
+
+func (s *Service) recordFieldSuccess(tmpl *Template, record Record) {
+	for _, field := range tmpl.Fields {
+		atomic.AddUint64(s.counter(&s.fieldTotal, field.Name), 1)
+		if _, ok := record[field.Name]; ok {
+			atomic.AddUint64(s.counter(&s.fieldHit, field.Name), 1)
+		}
+	}
+}
+
+func (s *Service) counter(m *sync.Map, key string) *uint64 {
+	v, _ := m.LoadOrStore(key, new(uint64))
+	return v.(*uint64)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validator checks an extracted field value before it is accepted.
+type Validator struct {
+	Type    string `json:"type"`    // "regex", "int", "decimal", "date"
+	Pattern string `json:"pattern"` // used when Type == "regex"
+	Layout  string `json:"layout"`  // Go time layout, used when Type == "date"
+
+	compiled *regexp.Regexp
+}
+
+// FieldRule describes how to locate, clean up and validate a single named
+// field within the text of a PDF.
+type FieldRule struct {
+	Name      string         `json:"name"`
+	Patterns  []string       `json:"patterns"`
+	Group     int            `json:"group"`      // capture group index, defaults to 1
+	GroupName string         `json:"group_name"` // named capture group, takes precedence over Group
+	Pipeline  []PipelineStep `json:"pipeline"`
+	Validator *Validator     `json:"validator"`
+
+	compiled []*regexp.Regexp
+}
+
+// PipelineStep is one post-processing step applied to a field's raw match.
+// Args are plain JSON strings, so a regex-replace pattern or a date layout
+// can contain any character — including "|" or ":" — without colliding with
+// a delimiter, unlike an earlier "name:arg1:arg2"/"name|arg1|arg2" encoding.
+type PipelineStep struct {
+	Type string   `json:"type"`
+	Args []string `json:"args"`
+}
+
+// Template describes a single PDF layout: the regex used to recognize it
+// and the fields it knows how to extract from matching documents.
+type Template struct {
+	Name          string        `json:"name"`
+	Discriminator string        `json:"discriminator"`
+	Fields        []FieldRule   `json:"fields"`
+	Scrapers      []ScraperRule `json:"scrapers"`
+
+	discriminatorRe *regexp.Regexp
+}
+
+// loadTemplates reads and compiles every *.json template file in dir.
+func loadTemplates(dir string) ([]*Template, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("globbing templates in %s: %w", dir, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no template files found in %s", dir)
+	}
+
+	templates := make([]*Template, 0, len(paths))
+	for _, path := range paths {
+		tmpl, err := loadTemplate(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading template %s: %w", path, err)
+		}
+		templates = append(templates, tmpl)
+	}
+	return templates, nil
+}
+
+func loadTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading template file: %w", err)
+	}
+
+	var tmpl Template
+	if err := json.Unmarshal(data, &tmpl); err != nil {
+		return nil, fmt.Errorf("unmarshalling template: %w", err)
+	}
+	if tmpl.Name == "" {
+		tmpl.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if tmpl.Discriminator == "" {
+		return nil, fmt.Errorf("template %s has no discriminator", tmpl.Name)
+	}
+
+	tmpl.discriminatorRe, err = regexp.Compile(tmpl.Discriminator)
+	if err != nil {
+		return nil, fmt.Errorf("compiling discriminator: %w", err)
+	}
+
+	for i := range tmpl.Fields {
+		field := &tmpl.Fields[i]
+		if len(field.Patterns) == 0 {
+			return nil, fmt.Errorf("field %q has no patterns", field.Name)
+		}
+		for _, pattern := range field.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("compiling pattern for field %q: %w", field.Name, err)
+			}
+			field.compiled = append(field.compiled, re)
+		}
+		if field.Validator != nil && field.Validator.Type == "regex" {
+			field.Validator.compiled, err = regexp.Compile(field.Validator.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("compiling validator for field %q: %w", field.Name, err)
+			}
+		}
+	}
+
+	for i := range tmpl.Scrapers {
+		if err := compileScraper(&tmpl.Scrapers[i]); err != nil {
+			return nil, fmt.Errorf("template %s: %w", tmpl.Name, err)
+		}
+	}
+
+	return &tmpl, nil
+}
+
+// scraperRules returns the pointers to tmpl's own scrapers plus any global
+// scrapers loaded from --scraper-dir, so both sources run together.
+func scraperRules(tmpl *Template, globalScrapers []*ScraperRule) []*ScraperRule {
+	rules := make([]*ScraperRule, 0, len(tmpl.Scrapers)+len(globalScrapers))
+	for i := range tmpl.Scrapers {
+		rules = append(rules, &tmpl.Scrapers[i])
+	}
+	rules = append(rules, globalScrapers...)
+	return rules
+}
+
+// selectTemplate returns the first template whose discriminator matches text.
+func selectTemplate(templates []*Template, text string) (*Template, error) {
+	for _, tmpl := range templates {
+		if tmpl.discriminatorRe.MatchString(text) {
+			return tmpl, nil
+		}
+	}
+	return nil, fmt.Errorf("no template matched this document")
+}
+
+// Record is a schema-driven, field-name-keyed extraction result.
+type Record map[string]string
+
+// extractRecord runs every field rule of tmpl against text and returns the
+// resulting record. Fields that fail to match or fail validation are left
+// out of the record rather than aborting the whole extraction.
+func extractRecord(text string, tmpl *Template) Record {
+	record := make(Record, len(tmpl.Fields))
+	for _, field := range tmpl.Fields {
+		value, ok := extractField(text, field)
+		if !ok {
+			continue
+		}
+		record[field.Name] = value
+	}
+	return record
+}
+
+func extractField(text string, field FieldRule) (string, bool) {
+	var raw string
+	found := false
+	for _, re := range field.compiled {
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		groupIdx := field.Group
+		if field.GroupName != "" {
+			groupIdx = re.SubexpIndex(field.GroupName)
+			if groupIdx < 0 {
+				continue
+			}
+		} else if groupIdx == 0 {
+			groupIdx = 1
+		}
+		if groupIdx >= len(match) {
+			continue
+		}
+		raw = match[groupIdx]
+		found = true
+		break
+	}
+	if !found {
+		return "", false
+	}
+
+	value, err := applyFieldPipeline(raw, field.Pipeline)
+	if err != nil {
+		log.Printf("Field %q: pipeline step failed, dropping field: %v", field.Name, err)
+		return "", false
+	}
+
+	if field.Validator != nil {
+		if err := validateValue(value, field.Validator); err != nil {
+			log.Printf("Field %q: validation failed, dropping field: %v", field.Name, err)
+			return "", false
+		}
+	}
+
+	return value, true
+}
+
+// applyFieldPipeline applies a sequence of post-processing steps to value,
+// left to right.
+func applyFieldPipeline(value string, steps []PipelineStep) (string, error) {
+	for _, step := range steps {
+		var err error
+		switch step.Type {
+		case "trim":
+			value = strings.TrimSpace(value)
+		case "strip-dots":
+			value = strings.ReplaceAll(value, ".", "")
+		case "uppercase":
+			value = strings.ToUpper(value)
+		case "replace":
+			if len(step.Args) != 2 {
+				return "", fmt.Errorf("replace step requires [old, new] args")
+			}
+			value = strings.ReplaceAll(value, step.Args[0], step.Args[1])
+		case "regex-replace":
+			if len(step.Args) != 2 {
+				return "", fmt.Errorf("regex-replace step requires [pattern, replacement] args")
+			}
+			re, compileErr := regexp.Compile(step.Args[0])
+			if compileErr != nil {
+				return "", fmt.Errorf("compiling regex-replace pattern: %w", compileErr)
+			}
+			value = re.ReplaceAllString(value, step.Args[1])
+		case "date-reformat":
+			if len(step.Args) != 2 {
+				return "", fmt.Errorf("date-reformat step requires [inLayout, outLayout] args")
+			}
+			value, err = reformatDate(value, step.Args[0], step.Args[1])
+			if err != nil {
+				return "", err
+			}
+		default:
+			return "", fmt.Errorf("unknown pipeline step %q", step.Type)
+		}
+	}
+	return value, nil
+}
+
+func reformatDate(value, inLayout, outLayout string) (string, error) {
+	t, err := time.Parse(inLayout, value)
+	if err != nil {
+		return "", fmt.Errorf("parsing date %q with layout %q: %w", value, inLayout, err)
+	}
+	return t.Format(outLayout), nil
+}
+
+func validateValue(value string, v *Validator) error {
+	switch v.Type {
+	case "regex":
+		if !v.compiled.MatchString(value) {
+			return fmt.Errorf("value %q does not match validator pattern", value)
+		}
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("value %q is not a valid int: %w", value, err)
+		}
+	case "decimal":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("value %q is not a valid decimal: %w", value, err)
+		}
+	case "date":
+		if _, err := time.Parse(v.Layout, value); err != nil {
+			return fmt.Errorf("value %q does not match date layout %q: %w", value, v.Layout, err)
+		}
+	default:
+		return fmt.Errorf("unknown validator type %q", v.Type)
+	}
+	return nil
+}
+
+// unionFields returns the union of field names across templates, in first-
+// seen order, for use as CSV columns when records come from more than one
+// template.
+func unionFields(templates []*Template) []string {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, tmpl := range templates {
+		for _, field := range tmpl.Fields {
+			if seen[field.Name] {
+				continue
+			}
+			seen[field.Name] = true
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields
+}